@@ -0,0 +1,71 @@
+// Package commands implements the pacmod command line interface.
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Command is a single pacmod subcommand.
+type Command struct {
+	Name  string
+	Short string
+	Run   func(args []string) error
+}
+
+// RootCommand dispatches to pacmod's subcommands.
+type RootCommand struct {
+	commands []*Command
+}
+
+// NewDefaultCommand builds the root command with all of pacmod's
+// subcommands registered.
+func NewDefaultCommand() *RootCommand {
+	return &RootCommand{
+		commands: []*Command{
+			newPackCommand(),
+			newPackBatchCommand(),
+			newUnpackCommand(),
+			newServeCommand(),
+		},
+	}
+}
+
+// Execute parses os.Args and runs the matching subcommand.
+func (r *RootCommand) Execute() error {
+	if len(os.Args) < 2 {
+		r.usage()
+		return fmt.Errorf("no command specified")
+	}
+
+	name := os.Args[1]
+	for _, cmd := range r.commands {
+		if cmd.Name != name {
+			continue
+		}
+		if err := cmd.Run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "pacmod %s: %v\n", name, err)
+			return err
+		}
+		return nil
+	}
+
+	r.usage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func (r *RootCommand) usage() {
+	fmt.Fprintln(os.Stderr, "usage: pacmod <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range r.commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.Name, cmd.Short)
+	}
+}
+
+// newFlagSet returns a FlagSet that prints its own usage on error instead
+// of exiting the process, so subcommand errors can propagate through Run.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	return fs
+}
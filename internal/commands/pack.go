@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"github.com/wenit/pacmod/pack"
+)
+
+func newPackCommand() *Command {
+	return &Command{
+		Name:  "pack",
+		Short: "pack a Go module into the proxy archive layout",
+		Run:   runPack,
+	}
+}
+
+func runPack(args []string) error {
+	fs := newFlagSet("pack")
+	version := fs.String("version", "", "version of the module to pack")
+	output := fs.String("o", ".", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := "."
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	return pack.Module(path, *version, *output)
+}
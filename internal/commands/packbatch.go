@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wenit/pacmod/pack"
+)
+
+func newPackBatchCommand() *Command {
+	return &Command{
+		Name:  "pack-batch",
+		Short: "pack many module versions concurrently from a manifest",
+		Run:   runPackBatch,
+	}
+}
+
+func runPackBatch(args []string) error {
+	fs := newFlagSet("pack-batch")
+	output := fs.String("o", ".", "output directory, laid out as <module>/@v/<version>.*")
+	jobs := fs.Int("jobs", 0, "number of modules to pack concurrently (default GOMAXPROCS)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("pack-batch requires a manifest file as its argument")
+	}
+
+	entries, err := readManifest(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	results := pack.Batch(entries, *output, &pack.BatchConfig{Jobs: *jobs})
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "pacmod pack-batch: %s@%s: %v\n", result.Entry.Path, result.Entry.Version, result.Err)
+			continue
+		}
+		fmt.Printf("packed %s@%s\n", result.Entry.Path, result.Entry.Version)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d modules failed to pack", failed, len(results))
+	}
+	return nil
+}
+
+// readManifest parses a pack-batch manifest: either a JSON array of
+// {"path": "...", "version": "..."} objects, or a plain text file with
+// one "path@version" entry per line (blank lines and "#" comments
+// ignored).
+func readManifest(manifestPath string) ([]pack.BatchEntry, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []pack.BatchEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid JSON manifest: %w", err)
+		}
+		return entries, nil
+	}
+
+	var entries []pack.BatchEntry
+	scanner := bufio.NewScanner(strings.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path, version, ok := strings.Cut(line, "@")
+		if !ok {
+			return nil, fmt.Errorf("invalid manifest line %q: expected path@version", line)
+		}
+		entries = append(entries, pack.BatchEntry{Path: path, Version: version})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
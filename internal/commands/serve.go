@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"github.com/wenit/pacmod/internal/modproxy"
+	"github.com/wenit/pacmod/pack"
+)
+
+func newServeCommand() *Command {
+	return &Command{
+		Name:  "serve",
+		Short: "serve packed modules over the Go module proxy protocol",
+		Run:   runServe,
+	}
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	root := fs.String("root", ".", "root directory of packed modules, laid out as <module>/@v/<version>.*")
+	uploadUser := fs.String("upload-user", "", "basic auth username required to PUT new module archives")
+	uploadPass := fs.String("upload-pass", "", "basic auth password required to PUT new module archives")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	srv := &proxyServer{
+		root:       *root,
+		uploadUser: *uploadUser,
+		uploadPass: *uploadPass,
+	}
+
+	fmt.Printf("pacmod: serving %s on %s\n", *root, *addr)
+	return http.ListenAndServe(*addr, srv)
+}
+
+// proxyServer implements the GOPROXY protocol
+// (https://go.dev/ref/mod#goproxy-protocol) backed by a directory of
+// previously packed modules.
+type proxyServer struct {
+	root       string
+	uploadUser string
+	uploadPass string
+}
+
+func (s *proxyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	modulePath, op, version, ok := splitProxyPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := validateModulePath(modulePath); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if version != "" && !semver.IsValid(version) {
+		http.Error(w, fmt.Sprintf("invalid version %q", version), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case op == "list":
+		s.serveList(w, modulePath)
+	case op == "latest":
+		s.serveLatest(w, modulePath)
+	case op == "info":
+		s.serveFile(w, modulePath, version+".info", "application/json")
+	case op == "mod":
+		s.serveFile(w, modulePath, version+".mod", "text/plain; charset=UTF-8")
+	case op == "zip" && r.Method == http.MethodPut:
+		s.handleUpload(w, r, modulePath, version)
+	case op == "zip":
+		s.serveFile(w, modulePath, version+".zip", "application/zip")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// validateModulePath rejects anything that isn't a well-formed module
+// path before it's ever joined into a filesystem path: in particular
+// ".."/empty segments and leading slashes, which would otherwise let a
+// request walk outside -root.
+func validateModulePath(escapedModulePath string) error {
+	unescaped, err := modproxy.UnescapePath(escapedModulePath)
+	if err != nil {
+		return fmt.Errorf("invalid module path: %w", err)
+	}
+	if err := module.CheckPath(unescaped); err != nil {
+		return fmt.Errorf("invalid module path: %w", err)
+	}
+	return nil
+}
+
+// splitProxyPath breaks a request path into a module path and the proxy
+// operation it names: "list" for @v/list, "latest" for @latest, or one of
+// "info"/"mod"/"zip" plus the version the request is for.
+func splitProxyPath(path string) (module, op, version string, ok bool) {
+	path = strings.TrimPrefix(path, "/")
+
+	if mod, ok := cutSuffix(path, "/@latest"); ok {
+		return mod, "latest", "", true
+	}
+	if mod, ok := cutSuffix(path, "/@v/list"); ok {
+		return mod, "list", "", true
+	}
+
+	mod, rest, ok := cutLast(path, "/@v/")
+	if !ok {
+		return "", "", "", false
+	}
+	for _, ext := range []string{".info", ".mod", ".zip"} {
+		if v, ok := cutSuffix(rest, ext); ok {
+			return mod, strings.TrimPrefix(ext, "."), v, true
+		}
+	}
+	return "", "", "", false
+}
+
+func cutSuffix(s, suffix string) (string, bool) {
+	if !strings.HasSuffix(s, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(s, suffix), true
+}
+
+func cutLast(s, sep string) (before, after string, ok bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+func (s *proxyServer) serveList(w http.ResponseWriter, module string) {
+	versions, err := modproxy.ListVersions(s.root, module)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	for _, v := range versions {
+		fmt.Fprintln(w, v)
+	}
+}
+
+func (s *proxyServer) serveLatest(w http.ResponseWriter, module string) {
+	versions, err := modproxy.ListVersions(s.root, module)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	latest, err := modproxy.Latest(versions)
+	if err != nil {
+		http.NotFound(w, nil)
+		return
+	}
+
+	s.serveFile(w, module, latest+".info", "application/json")
+}
+
+func (s *proxyServer) serveFile(w http.ResponseWriter, module, name, contentType string) {
+	path := filepath.Join(modproxy.VersionDir(s.root, module), name)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, nil)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	io.Copy(w, file)
+}
+
+func (s *proxyServer) handleUpload(w http.ResponseWriter, r *http.Request, module, version string) {
+	if s.uploadUser == "" && s.uploadPass == "" {
+		http.Error(w, "uploads are disabled: no -upload-user/-upload-pass configured", http.StatusForbidden)
+		return
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(s.uploadUser)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(s.uploadPass)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pacmod"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dir := modproxy.VersionDir(s.root, module)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	zipPath := filepath.Join(dir, version+".zip")
+	out, err := os.Create(zipPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := io.Copy(out, r.Body); err != nil {
+		out.Close()
+		os.Remove(zipPath)
+		http.Error(w, fmt.Sprintf("unable to write uploaded archive: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(zipPath)
+		http.Error(w, fmt.Sprintf("unable to write uploaded archive: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := finishUpload(dir, zipPath, version); err != nil {
+		os.Remove(zipPath)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// finishUpload derives everything the proxy protocol needs from an
+// uploaded zip that the go command doesn't send itself: the .mod file
+// (extracted from the archive) and the .ziphash/.modhash pair chunk0-3
+// defines, so a module published only through this endpoint is usable the
+// same way one packed by pack.Module is.
+func finishUpload(dir, zipPath, version string) error {
+	goModContents, err := pack.ExtractGoMod(zipPath)
+	if err != nil {
+		return fmt.Errorf("uploaded archive is missing go.mod: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, version+".mod"), goModContents, 0644); err != nil {
+		return fmt.Errorf("could not write go.mod: %w", err)
+	}
+
+	zipHashValue, err := pack.ZipHash(zipPath)
+	if err != nil {
+		return fmt.Errorf("could not compute zip hash: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, version+".ziphash"), []byte(zipHashValue), 0644); err != nil {
+		return fmt.Errorf("could not write ziphash: %w", err)
+	}
+
+	modHashValue := pack.ModHashBytes(goModContents)
+	if err := os.WriteFile(filepath.Join(dir, version+".modhash"), []byte(modHashValue), 0644); err != nil {
+		return fmt.Errorf("could not write modhash: %w", err)
+	}
+
+	return writeUploadedInfo(dir, version)
+}
+
+func writeUploadedInfo(dir, version string) error {
+	infoPath := filepath.Join(dir, version+".info")
+	if _, err := os.Stat(infoPath); err == nil {
+		return nil
+	}
+
+	type infoFile struct {
+		Version string
+		Time    string
+	}
+	info := infoFile{Version: version, Time: time.Now().UTC().Format("2006-01-02T15:04:05Z")}
+
+	infoBytes, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("could not marshal info file: %w", err)
+	}
+	return os.WriteFile(infoPath, infoBytes, 0644)
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wenit/pacmod/pack"
+)
+
+// packIntoProxyLayout packs a throwaway module and lays the result out
+// under root the way serve expects: root/<module>/@v/<version>.*.
+func packIntoProxyLayout(t *testing.T, root, modulePath, version string) {
+	t.Helper()
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte("module "+modulePath+"\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packed := t.TempDir()
+	if err := pack.Module(src, version, packed); err != nil {
+		t.Fatalf("pack.Module: %v", err)
+	}
+
+	destDir := filepath.Join(root, modulePath, "@v")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	renames := map[string]string{
+		"source.zip":           version + ".zip",
+		version + ".info":      version + ".info",
+		"go.mod":               version + ".mod",
+		version + ".ziphash":   version + ".ziphash",
+		version + ".modhash":   version + ".modhash",
+	}
+	for from, to := range renames {
+		data, err := os.ReadFile(filepath.Join(packed, from))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(destDir, to), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestValidateModulePathRejectsTraversal(t *testing.T) {
+	cases := []string{"../evil", "foo/../../bar", "/abs/path", "", "a/../../../etc/passwd"}
+	for _, c := range cases {
+		if err := validateModulePath(c); err == nil {
+			t.Errorf("expected validateModulePath(%q) to be rejected", c)
+		}
+	}
+
+	if err := validateModulePath("example.com/foo/bar"); err != nil {
+		t.Errorf("expected a well-formed module path to be accepted, got: %v", err)
+	}
+}
+
+func TestServeHTTPRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	secretDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secretDir, "secret.mod"), []byte("do not leak"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &proxyServer{root: root}
+
+	// Simulate a request whose path walks out of -root via "..", the
+	// same shape curl --path-as-is can send: the server must reject it
+	// before ever building a filesystem path from it.
+	req := httptest.NewRequest(http.MethodGet, "/../"+filepath.Base(secretDir)+"/@v/secret.mod", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest && rec.Code != http.StatusNotFound {
+		t.Fatalf("expected traversal attempt to be rejected, got status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "do not leak") {
+		t.Fatalf("traversal request leaked file contents: %q", rec.Body.String())
+	}
+}
+
+func TestServeHTTPServesPackedModule(t *testing.T) {
+	root := t.TempDir()
+	packIntoProxyLayout(t, root, "example.com/widget", "v1.0.0")
+
+	srv := &proxyServer{root: root}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	t.Run("list", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/example.com/widget/@v/list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "v1.0.0") {
+			t.Fatalf("unexpected list response: status=%d body=%q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/example.com/widget/@latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "v1.0.0") {
+			t.Fatalf("unexpected latest response: status=%d body=%q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/example.com/widget/@v/v1.0.0.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected zip response status: %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown version 404s", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/example.com/widget/@v/v9.9.9.mod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestServeHTTPUploadWritesFullProxyLayout(t *testing.T) {
+	root := t.TempDir()
+	srv := &proxyServer{root: root, uploadUser: "ci", uploadPass: "secret"}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	// Pack a module to get a real, well-formed zip to upload.
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/uploaded\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	packed := t.TempDir()
+	if err := pack.Module(src, "v1.0.0", packed); err != nil {
+		t.Fatalf("pack.Module: %v", err)
+	}
+	zipData, err := os.ReadFile(filepath.Join(packed, "source.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/example.com/uploaded/@v/v1.0.0.zip", strings.NewReader(string(zipData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("ci", "secret")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+
+	versionDir := filepath.Join(root, "example.com", "uploaded", "@v")
+	for _, ext := range []string{".zip", ".info", ".mod", ".ziphash", ".modhash"} {
+		p := filepath.Join(versionDir, "v1.0.0"+ext)
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to exist after upload: %v", p, err)
+		}
+	}
+
+	// The real proxy protocol always needs .mod; confirm it's servable too.
+	resp, err = http.Get(server.URL + "/example.com/uploaded/@v/v1.0.0.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "module example.com/uploaded") {
+		t.Fatalf("unexpected .mod response after upload: status=%d body=%q", resp.StatusCode, body)
+	}
+}
+
+func TestServeHTTPUploadRequiresAuth(t *testing.T) {
+	root := t.TempDir()
+	srv := &proxyServer{root: root, uploadUser: "ci", uploadPass: "secret"}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	resp, err := http.DefaultClient.Do(mustRequest(t, http.MethodPut, server.URL+"/example.com/uploaded/@v/v1.0.0.zip", "not a real zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+}
+
+func mustRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"errors"
+
+	"github.com/wenit/pacmod/pack"
+)
+
+var errMissingArchive = errors.New("unpack requires a packed module directory or .zip file as its argument")
+
+func newUnpackCommand() *Command {
+	return &Command{
+		Name:  "unpack",
+		Short: "verify and extract a packed module archive",
+		Run:   runUnpack,
+	}
+}
+
+func runUnpack(args []string) error {
+	fs := newFlagSet("unpack")
+	expectedHash := fs.String("expected-hash", "", "h1: hash the archive must match, in addition to any sibling .ziphash file")
+	output := fs.String("o", ".", "directory to extract the module into")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errMissingArchive
+	}
+
+	cfg := &pack.UnpackConfig{ExpectedHash: *expectedHash}
+	return pack.Unpack(fs.Arg(0), *output, cfg)
+}
@@ -0,0 +1,123 @@
+// Package modproxy holds the bits of the Go module proxy protocol
+// (https://go.dev/ref/mod#goproxy-protocol) shared between the pacmod
+// commands that read and write the `<module>/@v/...` directory layout.
+package modproxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// EscapePath applies the proxy protocol's module-path escaping: every
+// uppercase letter is replaced with an exclamation mark followed by its
+// lowercase form, since module paths are served over case-insensitive
+// filesystems and URLs.
+func EscapePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('!')
+			b.WriteRune(r - 'A' + 'a')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// UnescapePath reverses EscapePath.
+func UnescapePath(escaped string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != '!' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(escaped) {
+			return "", fmt.Errorf("truncated escape sequence in %q", escaped)
+		}
+		b.WriteByte(escaped[i] - 'a' + 'A')
+	}
+	return b.String(), nil
+}
+
+// VersionDir returns the `@v` directory for a module under root, with the
+// module path escaped per the proxy protocol.
+func VersionDir(root, module string) string {
+	return filepath.Join(root, EscapePath(module), "@v")
+}
+
+// ListVersions returns the versions packed for module under root, sorted
+// ascending by semver.
+func ListVersions(root, module string) ([]string, error) {
+	dir := VersionDir(root, module)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to list versions: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if ext := filepath.Ext(entry.Name()); ext != ".info" {
+			continue
+		}
+		version := strings.TrimSuffix(entry.Name(), ".info")
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+
+	return versions, nil
+}
+
+// Latest picks the version @latest should resolve to: the highest release
+// (non-prerelease) version if one exists, otherwise the highest version of
+// any kind, including pseudo-versions.
+func Latest(versions []string) (string, error) {
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no versions available")
+	}
+
+	latest := ""
+	for _, v := range versions {
+		if semver.Prerelease(v) != "" {
+			continue
+		}
+		if latest == "" || semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	if latest != "" {
+		return latest, nil
+	}
+
+	// No release versions: fall back to the highest version of any kind,
+	// which covers pseudo-versions and prereleases.
+	latest = versions[0]
+	for _, v := range versions[1:] {
+		if semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,138 @@
+package pack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/wenit/pacmod/internal/modproxy"
+)
+
+// BatchEntry describes one module version for Batch to pack.
+type BatchEntry struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// BatchResult reports the outcome of packing a single BatchEntry.
+type BatchResult struct {
+	Entry BatchEntry
+	Err   error
+}
+
+// BatchConfig controls Batch's concurrency and per-module packing.
+type BatchConfig struct {
+	// Jobs is the number of entries packed concurrently. Zero (or
+	// negative) means runtime.GOMAXPROCS(0).
+	Jobs int
+
+	// PackConfig is passed through to WriteZip for every entry.
+	PackConfig *Config
+}
+
+func (c *BatchConfig) jobs() int {
+	if c == nil || c.Jobs <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return c.Jobs
+}
+
+func (c *BatchConfig) packConfig() *Config {
+	if c == nil {
+		return nil
+	}
+	return c.PackConfig
+}
+
+// Batch packs every entry concurrently, bounded by cfg.Jobs workers, and
+// writes each one into the standard proxy directory layout under outDir:
+// <outDir>/<module>/@v/<version>.{zip,info,mod,ziphash,modhash}. Each entry is
+// packed into its own temporary directory first and moved into place only
+// once it succeeds, so concurrent workers never collide on a shared
+// filename. It returns one BatchResult per entry, in the same order as
+// entries; a non-nil Err means that entry failed. An entry that fails before
+// its files are moved into place leaves nothing in the layout; the files are
+// renamed one at a time, so a failed rename leaves those already moved.
+func Batch(entries []BatchEntry, outDir string, cfg *BatchConfig) []BatchResult {
+	results := make([]BatchResult, len(entries))
+
+	sem := make(chan struct{}, cfg.jobs())
+	var wg sync.WaitGroup
+	wg.Add(len(entries))
+
+	for i, entry := range entries {
+		sem <- struct{}{}
+		go func(i int, entry BatchEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = BatchResult{Entry: entry, Err: packBatchEntry(entry, outDir, cfg.packConfig())}
+		}(i, entry)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func packBatchEntry(entry BatchEntry, outDir string, cfg *Config) error {
+	moduleName, err := getModuleName(entry.Path)
+	if err != nil {
+		return fmt.Errorf("could not get module name: %w", err)
+	}
+
+	// Stage inside outDir, not the OS default temp dir, so the final
+	// os.Rename below is guaranteed to land on the same filesystem and
+	// stays atomic instead of failing with "invalid cross-device link".
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("could not create output directory: %w", err)
+	}
+	workDir, err := ioutil.TempDir(outDir, ".pacmod-batch-")
+	if err != nil {
+		return fmt.Errorf("could not create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	hashes, err := writeZipFile(entry.Path, moduleName, entry.Version, workDir, cfg)
+	if err != nil {
+		return fmt.Errorf("could not pack %s@%s: %w", moduleName, entry.Version, err)
+	}
+	if err := writeInfoFile(entry.Version, workDir); err != nil {
+		return fmt.Errorf("could not write info file: %w", err)
+	}
+	if err := writeHashFiles(workDir, entry.Version, hashes); err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(entry.Path, "go.mod"), filepath.Join(workDir, entry.Version+".mod")); err != nil {
+		return fmt.Errorf("could not copy go.mod: %w", err)
+	}
+
+	destDir := modproxy.VersionDir(outDir, moduleName)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("could not create output directory: %w", err)
+	}
+
+	moves := map[string]string{
+		filepath.Join(workDir, "source.zip"):             filepath.Join(destDir, entry.Version+".zip"),
+		filepath.Join(workDir, entry.Version+".info"):    filepath.Join(destDir, entry.Version+".info"),
+		filepath.Join(workDir, entry.Version+".mod"):     filepath.Join(destDir, entry.Version+".mod"),
+		filepath.Join(workDir, entry.Version+".ziphash"): filepath.Join(destDir, entry.Version+".ziphash"),
+		filepath.Join(workDir, entry.Version+".modhash"): filepath.Join(destDir, entry.Version+".modhash"),
+	}
+	for src, dst := range moves {
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("could not move %s into place: %w", filepath.Base(dst), err)
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", src, err)
+	}
+	return ioutil.WriteFile(dst, contents, 0644)
+}
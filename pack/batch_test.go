@@ -0,0 +1,80 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wenit/pacmod/internal/modproxy"
+)
+
+func writeTestModule(t *testing.T, modulePath string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+modulePath+"\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// TestBatchWritesProxyLayout packs several modules concurrently and checks
+// that every file the proxy layout expects - including .modhash, which a
+// previous version of this code computed and then silently dropped -
+// lands in outDir.
+func TestBatchWritesProxyLayout(t *testing.T) {
+	modules := []string{"example.com/one", "example.com/two", "example.com/three"}
+	entries := make([]BatchEntry, len(modules))
+	for i, m := range modules {
+		entries[i] = BatchEntry{Path: writeTestModule(t, m), Version: "v1.0.0"}
+	}
+
+	outDir := t.TempDir()
+	results := Batch(entries, outDir, nil)
+
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("entry %d (%s@%s) failed: %v", i, entries[i].Path, entries[i].Version, result.Err)
+		}
+
+		versionDir := modproxy.VersionDir(outDir, modules[i])
+		for _, ext := range []string{".zip", ".info", ".mod", ".ziphash", ".modhash"} {
+			p := filepath.Join(versionDir, entries[i].Version+ext)
+			if _, err := os.Stat(p); err != nil {
+				t.Errorf("expected %s to exist: %v", p, err)
+			}
+		}
+	}
+
+	// No staging directories should be left behind in outDir.
+	topLevel, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range topLevel {
+		if e.Name()[0] == '.' {
+			t.Errorf("leftover staging directory in outDir: %s", e.Name())
+		}
+	}
+}
+
+// TestBatchReportsFailures checks that a bad entry fails on its own
+// without affecting the others.
+func TestBatchReportsFailures(t *testing.T) {
+	entries := []BatchEntry{
+		{Path: writeTestModule(t, "example.com/good"), Version: "v1.0.0"},
+		{Path: t.TempDir(), Version: "v1.0.0"}, // no go.mod: should fail
+	}
+
+	outDir := t.TempDir()
+	results := Batch(entries, outDir, nil)
+
+	if results[0].Err != nil {
+		t.Errorf("expected first entry to succeed, got: %v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Error("expected second entry to fail")
+	}
+}
@@ -0,0 +1,99 @@
+package pack
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// hashLine formats a single entry of the "h1:" dirhash algorithm
+// (golang.org/x/mod/sumdb/dirhash.Hash1): "<hex sha256 of contents>  <name>\n".
+func hashLine(name string, sum []byte) string {
+	return fmt.Sprintf("%x  %s\n", sum, name)
+}
+
+// hash1 combines hashLine-formatted lines into the final "h1:" dirhash:
+// the lines are sorted lexicographically, concatenated, hashed with
+// sha256, then base64-std-encoded and prefixed with "h1:".
+func hash1(lines []string) string {
+	sorted := append([]string(nil), lines...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, line := range sorted {
+		io.WriteString(h, line)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ZipHash computes the h1: hash of an already-written module zip archive.
+// It is exported for callers that only have a zip file on disk - such as
+// an HTTP upload endpoint - and have no source tree to repack with
+// WriteZip.
+func ZipHash(zipPath string) (string, error) {
+	return zipHash(zipPath)
+}
+
+// zipHash computes the h1: hash of an already-written module zip archive,
+// the same way go.sum records it: the hash of every file the zip
+// contains, keyed by its path inside the archive (e.g.
+// "module@version/go.mod").
+func zipHash(zipPath string) (string, error) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open zip file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("unable to stat zip file: %w", err)
+	}
+
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return "", fmt.Errorf("unable to read zip file: %w", err)
+	}
+
+	lines := make([]string, 0, len(r.File))
+	for _, zf := range r.File {
+		rc, err := zf.Open()
+		if err != nil {
+			return "", fmt.Errorf("unable to open %s in zip: %w", zf.Name, err)
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", fmt.Errorf("unable to read %s in zip: %w", zf.Name, err)
+		}
+		lines = append(lines, hashLine(zf.Name, h.Sum(nil)))
+	}
+
+	return hash1(lines), nil
+}
+
+// modHash computes the h1: hash of a module's go.mod file, the same way
+// go.sum records it: as a single-line dirhash keyed by the literal name
+// "go.mod".
+func modHash(goModPath string) (string, error) {
+	contents, err := ioutil.ReadFile(goModPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read go.mod: %w", err)
+	}
+	return ModHashBytes(contents), nil
+}
+
+// ModHashBytes computes the h1: hash of go.mod file contents already held
+// in memory, the same way go.sum records it. Exported alongside ZipHash
+// for callers that received an archive over the network rather than
+// reading it from a source tree.
+func ModHashBytes(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hash1([]string{hashLine("go.mod", sum[:])})
+}
@@ -3,6 +3,7 @@ package pack
 import (
 	"archive/zip"
 	"bufio"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,21 +12,37 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/mod/module"
 )
 
 // Module packs the module at the given path and version then
 // outputs the result to the specified output directory
 func Module(path string, version string, outputDirectory string) error {
+	return ModuleWithConfig(path, version, outputDirectory, nil)
+}
+
+// ModuleWithConfig packs the module at the given path and version, the
+// same as Module, but lets the caller override the default size limits
+// and exclusion rules via cfg. A nil cfg behaves like Module.
+//
+// It is a thin filesystem wrapper around WriteZip and WriteInfo: it
+// always names the archive source.zip and writes it, the version's
+// .info file, a copy of go.mod, and the .ziphash/.modhash files into
+// outputDirectory. The go.mod copy is skipped when outputDirectory is ".".
+func ModuleWithConfig(path string, version string, outputDirectory string, cfg *Config) error {
 	moduleName, err := getModuleName(path)
 	if err != nil {
 		return fmt.Errorf("could not get module name: %w", err)
 	}
 
-	if err := createZipArchive(path, moduleName, version, outputDirectory); err != nil {
+	hashes, err := writeZipFile(path, moduleName, version, outputDirectory, cfg)
+	if err != nil {
 		return fmt.Errorf("could not create zip archive: %w", err)
 	}
 
-	if err := createInfoFile(version, outputDirectory); err != nil {
+	if err := writeInfoFile(version, outputDirectory); err != nil {
 		return fmt.Errorf("could not create info file: %w", err)
 	}
 
@@ -33,9 +50,67 @@ func Module(path string, version string, outputDirectory string) error {
 		return fmt.Errorf("could not copy module file: %w", err)
 	}
 
+	if err := writeHashFiles(outputDirectory, version, hashes); err != nil {
+		return fmt.Errorf("could not write hash files: %w", err)
+	}
+
 	return nil
 }
 
+// Default limits applied to a packed module, matching the go command's
+// own limits (see golang.org/x/mod/zip).
+const (
+	DefaultMaxFileSize  = 500 << 20
+	DefaultMaxTotalSize = 500 << 20
+)
+
+// Config controls the limits and exclusions applied when packing a module
+// zip archive. The zero value, like a nil *Config, applies the default size
+// limits and only the standard exclusions; set only the fields to override.
+type Config struct {
+	// MaxFileSize is the largest an individual uncompressed file may be.
+	// Zero means DefaultMaxFileSize.
+	MaxFileSize int64
+
+	// MaxTotalSize is the largest the total uncompressed archive may be.
+	// Zero means DefaultMaxTotalSize.
+	MaxTotalSize int64
+
+	// Exclude, if set, reports whether relPath (slash-separated, relative
+	// to the module root) should be left out of the archive in addition
+	// to the standard nested-module and nested-vendor exclusions.
+	Exclude func(relPath string) bool
+}
+
+func (c *Config) maxFileSize() int64 {
+	if c == nil || c.MaxFileSize == 0 {
+		return DefaultMaxFileSize
+	}
+	return c.MaxFileSize
+}
+
+func (c *Config) maxTotalSize() int64 {
+	if c == nil || c.MaxTotalSize == 0 {
+		return DefaultMaxTotalSize
+	}
+	return c.MaxTotalSize
+}
+
+func (c *Config) exclude(relPath string) bool {
+	if c == nil || c.Exclude == nil {
+		return false
+	}
+	return c.Exclude(relPath)
+}
+
+// Hashes holds the h1: dirhash values WriteZip computes while streaming a
+// module archive: the hash of the zip contents and the hash of the
+// module's go.mod, the same pair go.sum records for a module version.
+type Hashes struct {
+	Zip string
+	Mod string
+}
+
 func getModuleName(path string) (string, error) {
 	moduleFilePath := filepath.Join(path, "go.mod")
 	file, err := os.Open(moduleFilePath)
@@ -55,44 +130,124 @@ func getModuleName(path string) (string, error) {
 	return moduleHeaderParts[1], nil
 }
 
-func createZipArchive(path string, moduleName string, version string, outputDirectory string) error {
-	filePathsToArchive, err := getFilePathsToArchive(path)
-	if err != nil {
-		return fmt.Errorf("unable to get files to archive: %w", err)
+// WriteZip streams a module zip archive for the module at path, named
+// moduleName and version, to w, enforcing the same restrictions
+// ModuleWithConfig does. It returns the h1: zip and go.mod hashes computed
+// while writing, so callers don't need a second pass over the archive.
+func WriteZip(w io.Writer, path string, moduleName string, version string, cfg *Config) (Hashes, error) {
+	if err := module.Check(moduleName, version); err != nil {
+		return Hashes{}, fmt.Errorf("invalid module path/version %q@%q: %w", moduleName, version, err)
 	}
 
-	outputPath := filepath.Join(outputDirectory, "source.zip")
-	zipFile, err := os.Create(outputPath)
-	defer zipFile.Close()
+	filePathsToArchive, err := getFilePathsToArchive(path, cfg)
 	if err != nil {
-		return fmt.Errorf("unable to create zip file: %w", err)
+		return Hashes{}, fmt.Errorf("unable to get files to archive: %w", err)
 	}
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	zipWriter := zip.NewWriter(w)
+
+	maxFileSize := cfg.maxFileSize()
+	maxTotalSize := cfg.maxTotalSize()
+	var totalSize int64
+	var zipLines []string
 
 	for _, filePath := range filePathsToArchive {
-		fileToZip, err := os.Open(filePath)
+		info, err := os.Lstat(filePath)
 		if err != nil {
-			return fmt.Errorf("unable to open file: %w", err)
+			return Hashes{}, fmt.Errorf("unable to stat file: %w", err)
+		}
+		if err := checkRegularFile(filePath, info); err != nil {
+			return Hashes{}, err
 		}
-		defer fileToZip.Close()
 
-		zippedFilePath := getZipPath(path, filePath, moduleName, version)
-		zippedFileWriter, err := zipWriter.Create(zippedFilePath)
+		if info.Size() > maxFileSize {
+			return Hashes{}, fmt.Errorf("file %s is too large (%d bytes, max %d)", filePath, info.Size(), maxFileSize)
+		}
+		totalSize += info.Size()
+		if totalSize > maxTotalSize {
+			return Hashes{}, fmt.Errorf("module source is too large (exceeds %d bytes)", maxTotalSize)
+		}
+
+		zippedFilePath, err := getZipPath(path, filePath, moduleName, version)
 		if err != nil {
-			return fmt.Errorf("unable to add file to zip archive: %w", err)
+			return Hashes{}, err
 		}
 
-		if _, err := io.Copy(zippedFileWriter, fileToZip); err != nil {
-			return fmt.Errorf("unable to copy file contents to zip archive: %w", err)
+		line, err := copyFileToZip(zipWriter, filePath, zippedFilePath)
+		if err != nil {
+			return Hashes{}, err
 		}
+		zipLines = append(zipLines, line)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return Hashes{}, fmt.Errorf("unable to finalize zip archive: %w", err)
+	}
+
+	modHashValue, err := modHash(filepath.Join(path, "go.mod"))
+	if err != nil {
+		return Hashes{}, fmt.Errorf("could not compute go.mod hash: %w", err)
+	}
+
+	return Hashes{Zip: hash1(zipLines), Mod: modHashValue}, nil
+}
+
+func copyFileToZip(zipWriter *zip.Writer, filePath, zippedFilePath string) (string, error) {
+	fileToZip, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open file: %w", err)
+	}
+	defer fileToZip.Close()
+
+	zippedFileWriter, err := zipWriter.Create(zippedFilePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to add file to zip archive: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(zippedFileWriter, hasher), fileToZip); err != nil {
+		return "", fmt.Errorf("unable to copy file contents to zip archive: %w", err)
+	}
+
+	return hashLine(zippedFilePath, hasher.Sum(nil)), nil
+}
+
+// WriteInfo writes the JSON `<version>.info` body the proxy protocol
+// serves for a module version: its version string and the time it was
+// packed.
+func WriteInfo(w io.Writer, version string, t time.Time) error {
+	type infoFile struct {
+		Version string
+		Time    string
 	}
 
+	info := infoFile{
+		Version: version,
+		Time:    t.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+
+	infoBytes, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("could not marshal info file: %w", err)
+	}
+
+	_, err = w.Write(infoBytes)
+	return err
+}
+
+// checkRegularFile rejects anything the go command's own zip writer
+// rejects: symlinks, devices, named pipes, and other irregular files.
+func checkRegularFile(filePath string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("file %s is a symlink, which is not allowed in a module zip", filePath)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("file %s is not a regular file, which is not allowed in a module zip", filePath)
+	}
 	return nil
 }
 
-func getFilePathsToArchive(path string) ([]string, error) {
+func getFilePathsToArchive(path string, cfg *Config) ([]string, error) {
 	var files []string
 	err := filepath.Walk(path, func(currentFilePath string, fileInfo os.FileInfo, err error) error {
 		if err != nil {
@@ -105,12 +260,32 @@ func getFilePathsToArchive(path string) ([]string, error) {
 			return filepath.SkipDir
 		}
 
+		if fileInfo.IsDir() && currentFilePath != path {
+			if isNestedModuleRoot(currentFilePath) {
+				return filepath.SkipDir
+			}
+			if isNestedVendorDir(path, currentFilePath) {
+				return filepath.SkipDir
+			}
+		}
+
 		// Do not process directories
 		// returning nil tells the Walk() function to ignore this file
 		if fileInfo.IsDir() {
 			return nil
 		}
 
+		relPath, err := relSlash(path, currentFilePath)
+		if err != nil {
+			return err
+		}
+		if !utf8.ValidString(relPath) {
+			return fmt.Errorf("file path %q is not valid UTF-8", relPath)
+		}
+		if cfg.exclude(relPath) {
+			return nil
+		}
+
 		files = append(files, currentFilePath)
 
 		return nil
@@ -122,45 +297,66 @@ func getFilePathsToArchive(path string) ([]string, error) {
 	return files, nil
 }
 
-func getZipPath(path string, currentFilePath string, moduleName string, version string) string {
-	filePath := strings.TrimPrefix(currentFilePath, path)
-	return filepath.Join(fmt.Sprintf("%s@%s", moduleName, version), filePath)
+// isNestedModuleRoot reports whether dir, a subdirectory of the module
+// being packed, is itself the root of a nested module, and so should be
+// excluded the same way the go command excludes nested modules.
+func isNestedModuleRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
 }
 
-func createInfoFile(version string, outputDirectory string) error {
-	infoFilePath := filepath.Join(outputDirectory, version+".info")
-	file, err := os.Create(infoFilePath)
-	if err != nil {
-		return fmt.Errorf("could not create info file: %w", err)
-	}
-	defer file.Close()
+// isNestedVendorDir reports whether dir is a vendor directory other than
+// the root module's own vendor directory.
+func isNestedVendorDir(root, dir string) bool {
+	return filepath.Base(dir) == "vendor" && dir != filepath.Join(root, "vendor")
+}
 
-	type infoFile struct {
-		Version string
-		Time    string
+func relSlash(root, currentFilePath string) (string, error) {
+	rel, err := filepath.Rel(root, currentFilePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute relative path: %w", err)
 	}
+	return filepath.ToSlash(rel), nil
+}
 
-	currentTime := getInfoFileFormattedTime(time.Now())
-	info := infoFile{
-		Version: version,
-		Time:    currentTime,
+func getZipPath(path string, currentFilePath string, moduleName string, version string) (string, error) {
+	relPath, err := relSlash(path, currentFilePath)
+	if err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("%s@%s/%s", moduleName, version, relPath), nil
+}
 
-	infoBytes, err := json.Marshal(info)
+func writeZipFile(path, moduleName, version, outputDirectory string, cfg *Config) (Hashes, error) {
+	outputPath := filepath.Join(outputDirectory, "source.zip")
+	zipFile, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("could not marshal info file: %w", err)
+		return Hashes{}, fmt.Errorf("unable to create zip file: %w", err)
 	}
+	defer zipFile.Close()
+
+	return WriteZip(zipFile, path, moduleName, version, cfg)
+}
 
-	if _, err := file.Write(infoBytes); err != nil {
-		return fmt.Errorf("could not write info file: %w", err)
+func writeInfoFile(version, outputDirectory string) error {
+	infoFilePath := filepath.Join(outputDirectory, version+".info")
+	file, err := os.Create(infoFilePath)
+	if err != nil {
+		return fmt.Errorf("could not create info file: %w", err)
 	}
+	defer file.Close()
 
-	return nil
+	return WriteInfo(file, version, time.Now())
 }
 
-func getInfoFileFormattedTime(currentTime time.Time) string {
-	const infoFileTimeFormat = "2006-01-02T15:04:05Z"
-	return currentTime.Format(infoFileTimeFormat)
+func writeHashFiles(outputDirectory, version string, hashes Hashes) error {
+	if err := ioutil.WriteFile(filepath.Join(outputDirectory, version+".ziphash"), []byte(hashes.Zip), 0644); err != nil {
+		return fmt.Errorf("could not write ziphash file: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outputDirectory, version+".modhash"), []byte(hashes.Mod), 0644); err != nil {
+		return fmt.Errorf("could not write modhash file: %w", err)
+	}
+	return nil
 }
 
 func copyModuleFile(path string, outputDirectory string) error {
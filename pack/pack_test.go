@@ -0,0 +1,148 @@
+package pack
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func listZipNames(t *testing.T, data []byte) []string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("unable to read zip: %v", err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func zipContains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWriteZipExcludesNestedModule(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "main.go"), "package main\n")
+	mustWriteFile(t, filepath.Join(src, "nested", "go.mod"), "module example.com/outer/nested\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "nested", "nested.go"), "package nested\n")
+
+	var buf bytes.Buffer
+	if _, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", nil); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+
+	names := listZipNames(t, buf.Bytes())
+	if zipContains(names, "example.com/outer@v1.0.0/nested/go.mod") || zipContains(names, "example.com/outer@v1.0.0/nested/nested.go") {
+		t.Errorf("expected nested module directory to be excluded, got entries: %v", names)
+	}
+	if !zipContains(names, "example.com/outer@v1.0.0/main.go") {
+		t.Errorf("expected main.go to be archived, got entries: %v", names)
+	}
+}
+
+func TestWriteZipExcludesNestedVendorButNotRoot(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "vendor", "dep.go"), "package dep\n")
+	mustWriteFile(t, filepath.Join(src, "sub", "vendor", "dep.go"), "package dep\n")
+
+	var buf bytes.Buffer
+	if _, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", nil); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+
+	names := listZipNames(t, buf.Bytes())
+	if !zipContains(names, "example.com/outer@v1.0.0/vendor/dep.go") {
+		t.Errorf("expected root vendor directory to be archived, got entries: %v", names)
+	}
+	if zipContains(names, "example.com/outer@v1.0.0/sub/vendor/dep.go") {
+		t.Errorf("expected nested vendor directory to be excluded, got entries: %v", names)
+	}
+}
+
+func TestWriteZipRejectsSymlink(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "real.go"), "package main\n")
+	if err := os.Symlink(filepath.Join(src, "real.go"), filepath.Join(src, "link.go")); err != nil {
+		t.Skipf("symlinks not supported in this environment: %v", err)
+	}
+
+	var buf bytes.Buffer
+	_, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", nil)
+	if err == nil {
+		t.Fatal("expected WriteZip to reject a symlink, got nil error")
+	}
+}
+
+func TestWriteZipEnforcesMaxFileSize(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "big.go"), "package main\n// 0123456789")
+
+	cfg := &Config{MaxFileSize: 10}
+	var buf bytes.Buffer
+	_, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", cfg)
+	if err == nil {
+		t.Fatal("expected WriteZip to reject a file exceeding MaxFileSize, got nil error")
+	}
+}
+
+func TestWriteZipEnforcesMaxTotalSize(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "a.go"), "package main\n// aaaaaaaaaa")
+	mustWriteFile(t, filepath.Join(src, "b.go"), "package main\n// bbbbbbbbbb")
+
+	cfg := &Config{MaxFileSize: 1 << 20, MaxTotalSize: 20}
+	var buf bytes.Buffer
+	_, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", cfg)
+	if err == nil {
+		t.Fatal("expected WriteZip to reject an archive exceeding MaxTotalSize, got nil error")
+	}
+}
+
+func TestWriteZipHonorsCustomExclude(t *testing.T) {
+	src := t.TempDir()
+	mustWriteFile(t, filepath.Join(src, "go.mod"), "module example.com/outer\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(src, "main.go"), "package main\n")
+	mustWriteFile(t, filepath.Join(src, "testdata", "fixture.go"), "package testdata\n")
+
+	cfg := &Config{Exclude: func(relPath string) bool {
+		return filepath.Dir(relPath) == "testdata"
+	}}
+
+	var buf bytes.Buffer
+	if _, err := WriteZip(&buf, src, "example.com/outer", "v1.0.0", cfg); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+
+	names := listZipNames(t, buf.Bytes())
+	if zipContains(names, "example.com/outer@v1.0.0/testdata/fixture.go") {
+		t.Errorf("expected custom Exclude to drop testdata/fixture.go, got entries: %v", names)
+	}
+	if !zipContains(names, "example.com/outer@v1.0.0/main.go") {
+		t.Errorf("expected main.go to be archived, got entries: %v", names)
+	}
+}
+
+func mustWriteFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,244 @@
+package pack
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// markerFile is the name of the file Unpack writes to destination on
+// success, recording the hash it verified so a repeat call can skip
+// re-extraction.
+const markerFile = ".pacmod"
+
+// UnpackConfig controls the validation and limits Unpack applies to a
+// packed module archive before extracting it.
+type UnpackConfig struct {
+	// ExpectedHash, if set, must equal the archive's computed h1: zip
+	// hash (e.g. "h1:abcd...") or Unpack fails without extracting.
+	ExpectedHash string
+
+	// Limits overrides the default per-file and total uncompressed size
+	// limits enforced during extraction. A nil Limits uses the same
+	// defaults as pack.Module.
+	Limits *Config
+}
+
+// Unpack extracts the module archive at input - either a packed module
+// directory produced by Module, or a standalone .zip file - into
+// destination. A sibling .ziphash file, if present, is recomputed from the
+// archive and must match before extraction proceeds; cfg.ExpectedHash, if
+// set, is checked the same way. If destination already contains a
+// .pacmod marker matching the verified hash, Unpack returns immediately
+// without re-extracting.
+func Unpack(input, destination string, cfg *UnpackConfig) error {
+	zipPath, ziphashPath, err := resolveArchive(input)
+	if err != nil {
+		return err
+	}
+
+	hash, err := zipHash(zipPath)
+	if err != nil {
+		return fmt.Errorf("could not compute zip hash: %w", err)
+	}
+
+	if ziphashPath != "" {
+		recorded, err := ioutil.ReadFile(ziphashPath)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", ziphashPath, err)
+		}
+		if strings.TrimSpace(string(recorded)) != hash {
+			return fmt.Errorf("zip hash mismatch: archive is %s, %s recorded %s", hash, ziphashPath, strings.TrimSpace(string(recorded)))
+		}
+	}
+
+	if cfg != nil && cfg.ExpectedHash != "" && cfg.ExpectedHash != hash {
+		return fmt.Errorf("zip hash mismatch: archive is %s, expected %s", hash, cfg.ExpectedHash)
+	}
+
+	markerPath := filepath.Join(destination, markerFile)
+	if marker, err := ioutil.ReadFile(markerPath); err == nil && strings.TrimSpace(string(marker)) == hash {
+		return nil
+	}
+
+	var limits *Config
+	if cfg != nil {
+		limits = cfg.Limits
+	}
+	if err := extractZip(zipPath, destination, limits); err != nil {
+		return fmt.Errorf("could not extract zip: %w", err)
+	}
+
+	if err := ioutil.WriteFile(markerPath, []byte(hash), 0644); err != nil {
+		return fmt.Errorf("could not write %s marker: %w", markerFile, err)
+	}
+
+	return nil
+}
+
+// resolveArchive locates the zip archive and, if present, its sibling
+// .ziphash file for either a packed module directory or a standalone zip.
+func resolveArchive(input string) (zipPath, ziphashPath string, err error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to stat %s: %w", input, err)
+	}
+
+	if !info.IsDir() {
+		zipPath = input
+		ziphashPath = strings.TrimSuffix(input, filepath.Ext(input)) + ".ziphash"
+		if _, err := os.Stat(ziphashPath); err != nil {
+			ziphashPath = ""
+		}
+		return zipPath, ziphashPath, nil
+	}
+
+	zipPath = filepath.Join(input, "source.zip")
+	if matches, err := filepath.Glob(filepath.Join(input, "*.ziphash")); err == nil && len(matches) > 0 {
+		ziphashPath = matches[0]
+	}
+	return zipPath, ziphashPath, nil
+}
+
+// ExtractGoMod reads the go.mod contents embedded in a module zip
+// archive, without extracting the rest of the archive. It is exported for
+// callers that receive a zip directly - such as an HTTP upload endpoint -
+// and need its go.mod without a full Unpack.
+func ExtractGoMod(zipPath string) ([]byte, error) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open zip file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat zip file: %w", err)
+	}
+
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read zip file: %w", err)
+	}
+
+	for _, zf := range r.File {
+		if stripModulePrefix(zf.Name) != "go.mod" {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to open go.mod in zip: %w", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("zip archive does not contain a go.mod file")
+}
+
+// extractZip writes the contents of the module zip at zipPath into
+// destination, enforcing the same path and size restrictions pack applies
+// when creating the archive.
+func extractZip(zipPath, destination string, cfg *Config) error {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return fmt.Errorf("unable to open zip file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat zip file: %w", err)
+	}
+
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("unable to read zip file: %w", err)
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return fmt.Errorf("unable to create destination directory: %w", err)
+	}
+	cleanDestination := filepath.Clean(destination)
+
+	maxFileSize := cfg.maxFileSize()
+	maxTotalSize := cfg.maxTotalSize()
+	var totalSize int64
+
+	for _, zf := range r.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if zf.Mode()&os.ModeSymlink != 0 || !zf.Mode().IsRegular() {
+			return fmt.Errorf("entry %s is not a regular file, which is not allowed in a module zip", zf.Name)
+		}
+
+		relPath := stripModulePrefix(zf.Name)
+		if relPath == "" {
+			return fmt.Errorf("entry %s is missing the <module>@<version>/ prefix", zf.Name)
+		}
+		destPath := filepath.Join(destination, filepath.FromSlash(relPath))
+		if destPath != cleanDestination && !strings.HasPrefix(destPath, cleanDestination+string(os.PathSeparator)) {
+			return fmt.Errorf("entry %s escapes the destination directory", zf.Name)
+		}
+
+		if zf.UncompressedSize64 > uint64(maxFileSize) {
+			return fmt.Errorf("entry %s is too large (%d bytes, max %d)", zf.Name, zf.UncompressedSize64, maxFileSize)
+		}
+		totalSize += int64(zf.UncompressedSize64)
+		if totalSize > maxTotalSize {
+			return fmt.Errorf("archive contents exceed %d bytes", maxTotalSize)
+		}
+
+		if err := writeZipEntry(zf, destPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeZipEntry(zf *zip.File, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("unable to create directory for %s: %w", zf.Name, err)
+	}
+
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("unable to open %s in zip: %w", zf.Name, err)
+	}
+	defer rc.Close()
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", destPath, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, rc); err != nil {
+		return fmt.Errorf("unable to write %s: %w", destPath, err)
+	}
+
+	return nil
+}
+
+// stripModulePrefix removes the leading "<module>@<version>/" every entry
+// in a module zip carries, returning the path relative to the module root.
+// The split point is the first "/" after the "@", not the first "/" in
+// the whole name, since module paths routinely contain slashes themselves
+// (e.g. "github.com/foo/bar@v1.0.0/go.mod").
+func stripModulePrefix(name string) string {
+	at := strings.IndexByte(name, '@')
+	if at < 0 {
+		return ""
+	}
+	slash := strings.IndexByte(name[at:], '/')
+	if slash < 0 {
+		return ""
+	}
+	return name[at+slash+1:]
+}
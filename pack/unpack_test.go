@@ -0,0 +1,66 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestModuleRoundTrip packs a module whose path contains multiple slashes
+// (the common case: github.com/foo/bar) and unpacks the result, making
+// sure files land at <dest>/<relpath> rather than under a leftover
+// <module>@<version>/ prefix.
+func TestModuleRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/foo/bar\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package bar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packed := t.TempDir()
+	if err := Module(src, "v1.0.0", packed); err != nil {
+		t.Fatalf("Module: %v", err)
+	}
+
+	dest := t.TempDir()
+	if err := Unpack(packed, dest, nil); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	mainGo := filepath.Join(dest, "main.go")
+	if _, err := os.Stat(mainGo); err != nil {
+		t.Fatalf("expected %s to exist after unpack, got: %v", mainGo, err)
+	}
+
+	if entries, err := os.ReadDir(dest); err == nil {
+		for _, e := range entries {
+			if e.IsDir() && filepath.Ext(e.Name()) != "" {
+				t.Fatalf("unpack left a module@version directory in place: %s", e.Name())
+			}
+		}
+	}
+}
+
+// TestUnpackIsIdempotent checks that re-running Unpack against an
+// already-extracted, matching destination is a no-op rather than an error.
+func TestUnpackIsIdempotent(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/testmod\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packed := t.TempDir()
+	if err := Module(src, "v1.0.0", packed); err != nil {
+		t.Fatalf("Module: %v", err)
+	}
+
+	dest := t.TempDir()
+	if err := Unpack(packed, dest, nil); err != nil {
+		t.Fatalf("first Unpack: %v", err)
+	}
+	if err := Unpack(packed, dest, nil); err != nil {
+		t.Fatalf("second Unpack: %v", err)
+	}
+}